@@ -16,10 +16,16 @@ func lookConverterCommand() (string, error) {
 	return "", fmt.Errorf("not found converter cmd such also ffmpeg, avconv.")
 }
 
+var ffmpegPathRe = regexp.MustCompile("ffmpeg$")
+
+func isFfmpeg(path string) bool {
+	return ffmpegPathRe.MatchString(path)
+}
+
 func newConverterCmd(path, bitrate, output string, title string, author string) (*exec.Cmd, error) {
 
 	switch {
-	case regexp.MustCompile("ffmpeg$").MatchString(path):
+	case isFfmpeg(path):
 		return newFfmpegCmd(path, bitrate, output, title, author), nil
 	case regexp.MustCompile("avconv$").MatchString(path):
 		return newAvconvCmd(path, bitrate, output), nil
@@ -34,6 +40,7 @@ func newFfmpegCmd(ffmpeg, bitrate, output string, title string, author string) *
 	return exec.Command(
 		ffmpeg,
 		"-y",
+		"-f", "mpegts",
 		"-i", "-",
 		"-vn",
 		"-acodec", "copy",
@@ -48,6 +55,7 @@ func newAvconvCmd(avconv, bitrate, output string) *exec.Cmd {
 	return exec.Command(
 		avconv,
 		"-y",
+		"-f", "mpegts",
 		"-i", "-",
 		"-vn",
 		"-c:a", "copy",
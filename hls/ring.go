@@ -0,0 +1,98 @@
+// Package hls turns a live byte stream into a sliding-window HLS media
+// playlist: a rolling ring buffer of MPEG-TS segments plus the
+// #EXT-X-MEDIA-SEQUENCE/#EXT-X-TARGETDURATION bookkeeping a player needs
+// to keep following it.
+package hls
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Segment is one chunk of a ring buffer's MPEG-TS stream.
+type Segment struct {
+	Seq      int
+	Data     []byte
+	Start    time.Time
+	Duration time.Duration
+}
+
+// Ring holds the last window segments appended to it, discarding older
+// ones as new segments arrive.
+type Ring struct {
+	mu       sync.Mutex
+	window   int
+	segments []Segment
+	nextSeq  int
+}
+
+// NewRing creates a ring buffer that keeps at most window segments.
+func NewRing(window int) *Ring {
+	return &Ring{window: window}
+}
+
+// Append adds a new segment to the ring, evicting the oldest one if the
+// ring is already at its window size. start is the wall-clock time the
+// segment's data began being captured, used to anchor the playlist's
+// #EXT-X-PROGRAM-DATE-TIME.
+func (r *Ring) Append(data []byte, start time.Time, duration time.Duration) Segment {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seg := Segment{Seq: r.nextSeq, Data: data, Start: start, Duration: duration}
+	r.nextSeq++
+
+	r.segments = append(r.segments, seg)
+	if len(r.segments) > r.window {
+		r.segments = r.segments[len(r.segments)-r.window:]
+	}
+
+	return seg
+}
+
+// Segment returns the data for segment seq, if it's still in the ring.
+func (r *Ring) Segment(seq int) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, s := range r.segments {
+		if s.Seq == seq {
+			return s.Data, true
+		}
+	}
+
+	return nil, false
+}
+
+// Playlist renders the current window as an HLS media playlist.
+// targetDuration is advertised as #EXT-X-TARGETDURATION; it should be >=
+// the longest segment actually produced.
+func (r *Ring) Playlist(targetDuration time.Duration) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b strings.Builder
+
+	mediaSequence := r.nextSeq - len(r.segments)
+
+	fmt.Fprint(&b, "#EXTM3U\n")
+	fmt.Fprint(&b, "#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(targetDuration.Seconds()+0.999))
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", mediaSequence)
+
+	for i, s := range r.segments {
+		// Anchor only the first segment of the window: a player joining
+		// live derives every later segment's wall-clock time from this
+		// one plus the intervening #EXTINF durations (our ptsOffset).
+		if i == 0 && !s.Start.IsZero() {
+			fmt.Fprintf(&b, "#EXT-X-PROGRAM-DATE-TIME:%s\n", s.Start.Format(time.RFC3339Nano))
+		}
+
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n", s.Duration.Seconds())
+		fmt.Fprintf(&b, "seg-%d.ts\n", s.Seq)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,95 @@
+package hls
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// tsPacketSize is the fixed MPEG-TS packet size. Segments must end on a
+// packet boundary or players choke on the truncated packet at the cut.
+const tsPacketSize = 188
+
+// SegmentWriter is an io.Writer that buffers whatever is written to it
+// and, every interval, flushes the buffered bytes (rounded down to a
+// whole number of TS packets) into a Ring as a new segment.
+type SegmentWriter struct {
+	ring     *Ring
+	interval time.Duration
+
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	lastFlush time.Time
+	done      chan struct{}
+}
+
+// NewSegmentWriter starts a SegmentWriter that cuts a new segment into
+// ring every interval. Callers must Close it when done to stop the
+// background flush loop.
+func NewSegmentWriter(ring *Ring, interval time.Duration) *SegmentWriter {
+	w := &SegmentWriter{
+		ring:      ring,
+		interval:  interval,
+		lastFlush: time.Now(),
+		done:      make(chan struct{}),
+	}
+
+	go w.run()
+
+	return w
+}
+
+func (w *SegmentWriter) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.flush()
+		case <-w.done:
+			w.flush()
+			return
+		}
+	}
+}
+
+func (w *SegmentWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.buf.Write(p)
+}
+
+func (w *SegmentWriter) flush() {
+	w.mu.Lock()
+	n := (w.buf.Len() / tsPacketSize) * tsPacketSize
+	data := make([]byte, n)
+	copy(data, w.buf.Bytes()[:n])
+	remainder := append([]byte(nil), w.buf.Bytes()[n:]...)
+	w.buf.Reset()
+	w.buf.Write(remainder)
+
+	// Use the actual elapsed time rather than the fixed interval, since
+	// the final flush from Close() usually fires well short of a full
+	// interval and would otherwise tag a short chunk with a too-long
+	// #EXTINF.
+	start := w.lastFlush
+	now := time.Now()
+	w.lastFlush = now
+	w.mu.Unlock()
+
+	if len(data) == 0 {
+		return
+	}
+
+	w.ring.Append(data, start, now.Sub(start))
+}
+
+// Close stops the background flush loop, flushing the whole TS packets
+// still buffered as a final, possibly short, segment. A trailing partial
+// packet is dropped.
+func (w *SegmentWriter) Close() error {
+	close(w.done)
+	return nil
+}
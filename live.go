@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// RecordLive runs radiko's recording for station while tee-ing its
+// downloaded TS stream into server's HLS live endpoint, so
+// /live/{station}.m3u8 is listenable before the episode finishes. Once
+// the recording ends, the live endpoint starts redirecting to the
+// finished m4a.
+func RecordLive(ctx context.Context, radiko *Radiko, server *Server, station string) error {
+	writer := server.RegisterLive(station)
+	radiko.HLSSink = writer
+
+	err := radiko.Run(ctx)
+
+	writer.Close()
+
+	programDir := ""
+	if radiko.Result != nil {
+		programDir = fmt.Sprintf("%s_%s", radiko.Result.Prog.Ft, radiko.Result.Station)
+	}
+
+	server.FinishLive(station, programDir)
+
+	return err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+)
+
+// Podcast-standard EBU R128 targets (Apple/Spotify recommend -16 LUFS
+// integrated, -1.5 dBTP true peak).
+const (
+	loudnessTargetI   = "-16"
+	loudnessTargetTP  = "-1.5"
+	loudnessTargetLRA = "11"
+)
+
+// LoudnessMeasurement is ffmpeg's loudnorm pass-1 JSON summary, kept
+// around so pass 2 can apply a linear gain instead of guessing from a
+// single pass, and so it can be written into podcast.xml for auditing.
+type LoudnessMeasurement struct {
+	InputI       string `xml:"input_i" json:"input_i"`
+	InputTP      string `xml:"input_tp" json:"input_tp"`
+	InputLRA     string `xml:"input_lra" json:"input_lra"`
+	InputThresh  string `xml:"input_thresh" json:"input_thresh"`
+	TargetOffset string `xml:"target_offset" json:"target_offset"`
+}
+
+// LoudnessNormalizer re-encodes an m4a to the podcast-standard loudness
+// targets using ffmpeg's two-pass loudnorm filter.
+type LoudnessNormalizer struct {
+	Ffmpeg  string
+	Bitrate string
+}
+
+// Measure runs loudnorm in analysis-only mode (pass 1) over input and
+// parses the JSON summary it prints to stderr.
+func (n *LoudnessNormalizer) Measure(input string) (*LoudnessMeasurement, error) {
+	filter := fmt.Sprintf("loudnorm=I=%s:TP=%s:LRA=%s:print_format=json", loudnessTargetI, loudnessTargetTP, loudnessTargetLRA)
+
+	cmd := exec.Command(n.Ffmpeg, "-i", input, "-af", filter, "-f", "null", "-")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	return parseLoudnormSummary(stderr.Bytes())
+}
+
+var loudnormSummaryRe = regexp.MustCompile(`(?s)\{.*\}`)
+
+func parseLoudnormSummary(stderr []byte) (*LoudnessMeasurement, error) {
+	match := loudnormSummaryRe.Find(stderr)
+
+	if match == nil {
+		return nil, fmt.Errorf("no loudnorm summary found in ffmpeg output")
+	}
+
+	var m LoudnessMeasurement
+	if err := json.Unmarshal(match, &m); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// Normalize re-encodes input to output at the podcast-standard loudness
+// targets using m, the measurement from a prior Measure call.
+func (n *LoudnessNormalizer) Normalize(input string, output string, m *LoudnessMeasurement, title string, author string) error {
+	filter := fmt.Sprintf(
+		"loudnorm=I=%s:TP=%s:LRA=%s:measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true",
+		loudnessTargetI, loudnessTargetTP, loudnessTargetLRA,
+		m.InputI, m.InputTP, m.InputLRA, m.InputThresh, m.TargetOffset,
+	)
+
+	args := []string{
+		"-y",
+		"-i", input,
+		"-af", filter,
+		"-vn",
+		"-c:a", "aac",
+	}
+
+	if n.Bitrate != "" {
+		args = append(args, "-b:a", n.Bitrate)
+	}
+
+	args = append(args,
+		"-metadata", fmt.Sprintf("title=%s", title),
+		"-metadata", fmt.Sprintf("artist=%s", author),
+		"-metadata", "genre=radio",
+		output,
+	)
+
+	cmd := exec.Command(n.Ffmpeg, args...)
+	return cmd.Run()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// itunesNS is the DTD Apple Podcasts expects the itunes: prefix in an RSS
+// feed to be declared against.
+const itunesNS = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+
+// PodcastRss is the <rss> root of a podcast feed.
+type PodcastRss struct {
+	XMLName  xml.Name       `xml:"rss"`
+	Version  string         `xml:"version,attr"`
+	ItunesNS string         `xml:"xmlns:itunes,attr"`
+	Channel  PodcastChannel `xml:"channel"`
+}
+
+// NewPodcastRss builds an empty RSS 2.0 + itunes feed; callers fill in
+// Channel.
+func NewPodcastRss() *PodcastRss {
+	return &PodcastRss{
+		Version:  "2.0",
+		ItunesNS: itunesNS,
+	}
+}
+
+// PodcastChannel is the <channel> of a podcast feed, one per station
+// when served from /rss/{station}.
+type PodcastChannel struct {
+	Title          string          `xml:"title"`
+	Language       string          `xml:"language"`
+	ItunesType     string          `xml:"itunes:type"`
+	ItunesExplicit string          `xml:"itunes:explicit"`
+	ItunesCategory *ItunesCategory `xml:"itunes:category"`
+	ItunesOwner    *ItunesOwner    `xml:"itunes:owner"`
+	ItunesImage    *ItunesImage    `xml:"itunes:image,omitempty"`
+	Items          PodcastItems    `xml:"item"`
+}
+
+type ItunesCategory struct {
+	Text string `xml:"text,attr"`
+}
+
+type ItunesOwner struct {
+	Name  string `xml:"itunes:name"`
+	Email string `xml:"itunes:email"`
+}
+
+type ItunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+// PodcastItem is one <item>/episode.
+type PodcastItem struct {
+	Title          string       `xml:"title"`
+	Guid           string       `xml:"guid"`
+	ITunesAuthor   string       `xml:"itunes:author"`
+	ITunesSummary  string       `xml:"itunes:summary"`
+	ITunesDuration string       `xml:"itunes:duration"`
+	ITunesEpisode  int          `xml:"itunes:episode"`
+	ITunesSeason   int          `xml:"itunes:season"`
+	ITunesImage    *ItunesImage `xml:"itunes:image,omitempty"`
+	Enclosure      Enclosure    `xml:"enclosure"`
+	PubDate        PubDate      `xml:"pubDate"`
+}
+
+type Enclosure struct {
+	Url    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int    `xml:"length,attr"`
+}
+
+// PubDate marshals as RFC 1123Z, the form <pubDate> is expected in.
+type PubDate struct {
+	time.Time
+}
+
+func (p PubDate) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(p.Format(time.RFC1123Z), start)
+}
+
+// PodcastItems sorts oldest-to-newest by default; Server.rss wants
+// newest-first, so it wraps this in sort.Reverse.
+type PodcastItems []PodcastItem
+
+func (p PodcastItems) Len() int           { return len(p) }
+func (p PodcastItems) Less(i, j int) bool { return p[i].PubDate.Before(p[j].PubDate.Time) }
+func (p PodcastItems) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
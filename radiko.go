@@ -1,11 +1,12 @@
 package main
 
-// api for radiko, rtmpdump and ffmpeg command parameter
+// api for radiko and ffmpeg command parameter
 // are taken from
 // https://github.com/miyagawa/ripdiko
 // https://gist.github.com/saiten/875864
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/xml"
@@ -22,12 +23,21 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// segmentDownloadConcurrency bounds how many HLS segments are fetched in
+// parallel within a single download round.
+const segmentDownloadConcurrency = 4
+
 const (
 	radikoTimeLayout = "20060102150405"
-	playerUrl        = "http://radiko.jp/apps/js/flash/myplayer-release.swf"
+
+	// well-known full key from which the pc_html5 client derives the
+	// partial key for the auth2 challenge. radiko.jp slices keylength bytes starting at
+	// keyoffset out of this and expects it back base64-encoded.
+	radikoFullKey = "bcd151073c03b352e1ef2fd66c32209da9ca0afa"
 )
 
 type RadikoPrograms struct {
@@ -58,6 +68,12 @@ type RadikoProg struct {
 	Desc     string   `xml:"desc"`
 	Info     string   `xml:"info"`
 	Url      string   `xml:"url"`
+
+	// Loudness records the ffmpeg loudnorm pass-1 measurement when the
+	// recording went through LoudnessNormalizer, so podcast.xml keeps a
+	// record of what was measured and corrected. It isn't present on
+	// radiko.jp's own program XML, only on the copy we save ourselves.
+	Loudness *LoudnessMeasurement `xml:"loudness,omitempty"`
 }
 
 func (r *RadikoProg) FtTime() (time.Time, error) {
@@ -82,7 +98,7 @@ type RadikoResult struct {
 	Station string
 }
 
-func (r *RadikoResult) Save(dir string) error {
+func (r *RadikoResult) Save(ctx context.Context, dir string) error {
 	programDir := filepath.Join(dir, fmt.Sprintf("%s_%s", r.Prog.Ft, r.Station))
 
 	if err := os.MkdirAll(programDir, 0777); err != nil {
@@ -110,11 +126,57 @@ func (r *RadikoResult) Save(dir string) error {
 		return err
 	}
 
+	// Cover art is a nice-to-have for the RSS feed, not worth failing
+	// the whole save over if radiko's logo endpoint is unreachable.
+	if err := r.saveCover(ctx, programDir); err != nil {
+		r.Log("cover art err:", err)
+	}
+
 	r.Log("saved m4a:", m4aPath, " xml:", xmlPath)
 
 	return nil
 }
 
+// coverHTTPClient bounds how long a hung radiko.jp logo endpoint can
+// block saveCover, on top of whatever deadline ctx already carries.
+var coverHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// saveCover fetches the station's logo and caches it as cover.jpg next
+// to the recording, so Server can serve it as the episode's
+// itunes:image without hitting radiko.jp on every feed request.
+func (r *RadikoResult) saveCover(ctx context.Context, programDir string) error {
+	u := fmt.Sprintf("https://radiko.jp/v3/station/logo/%s/280x280.png", r.Station)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+
+	if err != nil {
+		return err
+	}
+
+	resp, err := coverHTTPClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if code := resp.StatusCode; code != 200 {
+		return fmt.Errorf("not status code:200, got:%d", code)
+	}
+
+	coverFile, err := os.Create(filepath.Join(programDir, "cover.jpg"))
+
+	if err != nil {
+		return err
+	}
+
+	defer coverFile.Close()
+
+	_, err = io.Copy(coverFile, resp.Body)
+	return err
+}
+
 func (r *RadikoResult) Log(v ...interface{}) {
 	log.Println("[radiko_result]", fmt.Sprint(v...))
 }
@@ -126,6 +188,17 @@ type Radiko struct {
 	Converter string
 	TempDir   string
 	Result    *RadikoResult
+
+	// Normalize opts this station into a two-pass EBU R128 loudness
+	// normalization after recording. It requires Converter to be
+	// ffmpeg, since the avconv fallback doesn't have loudnorm.
+	Normalize bool
+
+	// HLSSink, if set, receives a copy of every TS segment a live
+	// recording downloads, alongside the converter, so a live HLS
+	// endpoint can be served while the recording is still in progress.
+	// Time-free downloads (RecordTimeshift) are not copied to it.
+	HLSSink io.Writer
 }
 
 func (r *Radiko) Run(ctx context.Context) error {
@@ -334,6 +407,156 @@ func (r *Radiko) nowProgram(ctx context.Context, area string, station string) (*
 	return nil, errors.New("not found program")
 }
 
+// programOnDate looks up the program that started at ft on the given
+// station, using the program/date endpoint that covers the past 7 days
+// of broadcasts (radiko's "time-free" window).
+func (r *Radiko) programOnDate(ctx context.Context, date string, station string, ft string) (*RadikoProg, error) {
+	u, err := url.Parse("http://radiko.jp/v2/api/program/date")
+
+	if err != nil {
+		return nil, err
+	}
+
+	v := u.Query()
+	v.Set("date", date)
+	v.Set("station_id", station)
+
+	u.RawQuery = v.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var progs RadikoPrograms
+	err = r.httpDo(ctx, req, func(resp *http.Response, err error) error {
+		if err != nil {
+			return err
+		}
+
+		defer resp.Body.Close()
+
+		if code := resp.StatusCode; code != 200 {
+			return fmt.Errorf("not status code:200, got:%d", code)
+		}
+
+		return xml.NewDecoder(resp.Body).Decode(&progs)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range progs.Stations.Station {
+		if s.Id == station {
+			for _, prog := range s.Scd.Progs.Prog {
+				if prog.Ft == ft {
+					return &prog, nil
+				}
+			}
+		}
+	}
+
+	return nil, errors.New("not found program")
+}
+
+// RecordTimeshift downloads a program that already aired on station
+// between ft and to via radiko's time-free endpoint, rather than
+// whatever is airing live. The result is stored in r.Result, same as
+// Run, so callers save it through RadikoResult.Save exactly as they
+// would a live recording.
+//
+// There is no CLI entry point in this tree to expose ft/to as flags
+// (package main here has no func main at all, for Run either); wiring
+// one up is out of scope for this change and left to whatever binary
+// ends up embedding this package.
+func (r *Radiko) RecordTimeshift(ctx context.Context, station string, ft time.Time, to time.Time) error {
+	authtoken, _, err := r.auth(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	prog, err := r.programOnDate(ctx, ft.Format("20060102"), station, ft.Format(radikoTimeLayout))
+
+	if err != nil {
+		return err
+	}
+
+	output := filepath.Join(r.TempDir, "radiko_timeshift.m4a")
+
+	title := fmt.Sprintf("%s (%s)", prog.Title, ft)
+
+	r.Log("start timeshift recording ", prog.Title)
+
+	err = r.downloadTimeshift(ctx, authtoken, station, ft, to, r.Bitrate, output, title, prog.Pfm)
+
+	if _, fileErr := os.Stat(output); fileErr != nil {
+		return err
+	}
+
+	if err == nil {
+		if normErr := r.maybeNormalize(output, prog, title); normErr != nil {
+			r.Log("normalize err:", normErr)
+		}
+	}
+
+	r.Result = &RadikoResult{
+		Mp3Path: output,
+		Station: station,
+		Prog:    prog,
+	}
+
+	return err
+}
+
+// RecordProgram records station live for exactly durationSec seconds,
+// without re-resolving "what's airing now" the way record does. It
+// exists for schedulers that already know which program they want and
+// for how long: unlike r.run, it makes a single attempt and returns
+// whatever error download produced, rather than retrying against
+// whatever the station happens to be airing by then.
+func (r *Radiko) RecordProgram(ctx context.Context, station string, prog *RadikoProg, durationSec int64) error {
+	authtoken, _, err := r.auth(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	ft, err := prog.FtTime()
+
+	if err != nil {
+		return err
+	}
+
+	output := filepath.Join(r.TempDir, fmt.Sprintf("radiko_%s_%s.m4a", station, prog.Ft))
+
+	title := fmt.Sprintf("%s (%s)", prog.Title, ft)
+
+	r.Log("start recording ", prog.Title)
+
+	err = r.download(ctx, authtoken, station, fmt.Sprint(durationSec), r.Bitrate, output, title, prog.Pfm)
+
+	if _, fileErr := os.Stat(output); fileErr != nil {
+		return err
+	}
+
+	if err == nil {
+		if normErr := r.maybeNormalize(output, prog, title); normErr != nil {
+			r.Log("normalize err:", normErr)
+		}
+	}
+
+	r.Result = &RadikoResult{
+		Mp3Path: output,
+		Station: station,
+		Prog:    prog,
+	}
+
+	return err
+}
+
 func (r *Radiko) record(ctx context.Context, output string, station string, bitrate string, buffer int64) (*RadikoResult, error) {
 
 	authtoken, area, err := r.auth(ctx)
@@ -372,6 +595,12 @@ func (r *Radiko) record(ctx context.Context, output string, station string, bitr
 		return nil, err
 	}
 
+	if err == nil {
+		if normErr := r.maybeNormalize(output, prog, title); normErr != nil {
+			r.Log("normalize err:", normErr)
+		}
+	}
+
 	ret := &RadikoResult{
 		Mp3Path: output,
 		Station: station,
@@ -381,67 +610,142 @@ func (r *Radiko) record(ctx context.Context, output string, station string, bitr
 	return ret, err
 }
 
-func (r *Radiko) download(ctx context.Context, authtoken string, station string, sec string, bitrate string, output string, title string, author string) error {
+// maybeNormalize runs output through LoudnessNormalizer in place when
+// r.Normalize is set, and records what was measured onto prog so it
+// ends up in the saved podcast.xml.
+func (r *Radiko) maybeNormalize(output string, prog *RadikoProg, title string) error {
+	if !r.Normalize {
+		return nil
+	}
+
+	if !isFfmpeg(r.Converter) {
+		return fmt.Errorf("loudness normalization requires ffmpeg, got %s", r.Converter)
+	}
+
+	normalizer := &LoudnessNormalizer{Ffmpeg: r.Converter, Bitrate: r.Bitrate}
 
-	rtmpdump, err := exec.LookPath("rtmpdump")
+	measurement, err := normalizer.Measure(output)
 
 	if err != nil {
 		return err
 	}
 
-	rtmpdumpCmd := exec.Command(rtmpdump,
-		"--live",
-		"--quiet",
-		"-r", "rtmpe://f-radiko.smartstream.ne.jp",
-		"--playpath", "simul-stream.stream",
-		"--app", station+"/_definst_",
-		"-W", playerUrl,
-		"-C", `S:""`, "-C", `S:""`, "-C", `S:""`, "-C", "S:"+authtoken,
-		"--stop", sec,
-		"-o", "-",
-	)
+	normalized := output + ".norm.m4a"
 
-	converterCmd, err := newConverterCmd(r.Converter, bitrate, output, title, author)
+	if err := normalizer.Normalize(output, normalized, measurement, title, prog.Pfm); err != nil {
+		return err
+	}
 
-	if err != nil {
+	if err := RenameOrCopy(normalized, output); err != nil {
 		return err
 	}
 
-	r.Log("rtmpdump command: ", strings.Join(rtmpdumpCmd.Args, " "))
-	r.Log("converter command: ", strings.Join(converterCmd.Args, " "))
+	prog.Loudness = measurement
 
-	pipe, err := rtmpdumpCmd.StdoutPipe()
+	return nil
+}
+
+// download resolves the HLS playlist for station, pulls down AAC/TS
+// segments for sec seconds and pipes them into the converter command,
+// which muxes them into an m4a at output.
+func (r *Radiko) download(ctx context.Context, authtoken string, station string, sec string, bitrate string, output string, title string, author string) error {
+
+	secI, err := strconv.Atoi(sec)
 
 	if err != nil {
 		return err
 	}
 
-	converterCmd.Stdin = pipe
+	converterCmd, err := newConverterCmd(r.Converter, bitrate, output, title, author)
+
+	if err != nil {
+		return err
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+	converterCmd.Stdin = pipeReader
+
+	var sink io.Writer = pipeWriter
+	if r.HLSSink != nil {
+		sink = io.MultiWriter(pipeWriter, r.HLSSink)
+	}
+
+	r.Log("converter command: ", strings.Join(converterCmd.Args, " "))
+
+	dlCtx, cancel := context.WithTimeout(ctx, time.Duration(secI)*time.Second)
+	defer cancel()
 
 	errChan := make(chan error)
 	go func() {
 
 		if err := converterCmd.Start(); err != nil {
+			pipeReader.CloseWithError(err)
 			errChan <- err
 			return
 		}
 
-		if err := rtmpdumpCmd.Run(); err != nil {
-			errChan <- err
-			return
+		dlErr := r.downloadSegments(dlCtx, authtoken, station, sink)
+		if dlErr == context.DeadlineExceeded {
+			dlErr = nil
+		}
+		pipeWriter.CloseWithError(dlErr)
+
+		if err := converterCmd.Wait(); err != nil && dlErr == nil {
+			dlErr = err
+		}
+
+		errChan <- dlErr
+	}()
+
+	select {
+	case <-ctx.Done():
+		err := <-errChan
+		if err == nil {
+			err = ctx.Err()
 		}
+		return err
+	case err := <-errChan:
+		return err
+	}
+}
+
+// downloadTimeshift fetches the already-complete time-free playlist for
+// [ft, to) and pipes its segments into the converter command. Unlike the
+// live download it doesn't need a deadline: the playlist ends on its own
+// via #EXT-X-ENDLIST once every segment has been listed.
+func (r *Radiko) downloadTimeshift(ctx context.Context, authtoken string, station string, ft time.Time, to time.Time, bitrate string, output string, title string, author string) error {
+	converterCmd, err := newConverterCmd(r.Converter, bitrate, output, title, author)
 
-		if err := converterCmd.Wait(); err != nil {
+	if err != nil {
+		return err
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+	converterCmd.Stdin = pipeReader
+
+	r.Log("converter command: ", strings.Join(converterCmd.Args, " "))
+
+	errChan := make(chan error)
+	go func() {
+
+		if err := converterCmd.Start(); err != nil {
+			pipeReader.CloseWithError(err)
 			errChan <- err
 			return
 		}
 
-		errChan <- nil
+		dlErr := r.downloadTimeshiftSegments(ctx, authtoken, station, ft, to, pipeWriter)
+		pipeWriter.CloseWithError(dlErr)
+
+		if err := converterCmd.Wait(); err != nil && dlErr == nil {
+			dlErr = err
+		}
+
+		errChan <- dlErr
 	}()
 
 	select {
 	case <-ctx.Done():
-		rtmpdumpCmd.Process.Kill()
 		err := <-errChan
 		if err == nil {
 			err = ctx.Err()
@@ -450,29 +754,208 @@ func (r *Radiko) download(ctx context.Context, authtoken string, station string,
 	case err := <-errChan:
 		return err
 	}
+}
+
+// downloadSegments polls the station's HLS media playlist and streams
+// each new AAC/TS segment it finds into w, until ctx is done or the
+// playlist announces #EXT-X-ENDLIST.
+func (r *Radiko) downloadSegments(ctx context.Context, authtoken string, station string, w io.Writer) error {
+	chunklistUrl, err := r.chunklistUrl(ctx, authtoken, station)
+
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+
+	for {
+		playlist, err := r.fetchPlaylist(ctx, authtoken, chunklistUrl)
+
+		if err != nil {
+			return err
+		}
+
+		newSegs := []string{}
+		for _, seg := range playlist.Segments {
+			if seen[seg] {
+				continue
+			}
+			seen[seg] = true
+			newSegs = append(newSegs, seg)
+		}
+
+		if err := r.downloadSegmentsConcurrent(ctx, authtoken, newSegs, w); err != nil {
+			return err
+		}
+
+		if playlist.EndList {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(playlist.TargetDuration / 2):
+		}
+	}
+}
+
+// downloadSegmentsConcurrent fetches segs with up to
+// segmentDownloadConcurrency requests in flight, then writes their
+// bodies to w in order so the resulting TS stream stays playable.
+func (r *Radiko) downloadSegmentsConcurrent(ctx context.Context, authtoken string, segs []string, w io.Writer) error {
+	if len(segs) == 0 {
+		return nil
+	}
+
+	bufs := make([]bytes.Buffer, len(segs))
+	errs := make([]error, len(segs))
+
+	sem := make(chan struct{}, segmentDownloadConcurrency)
+	var wg sync.WaitGroup
+
+	for i, seg := range segs {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, seg string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			errs[i] = r.downloadSegment(ctx, authtoken, seg, &bufs[i])
+		}(i, seg)
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(bufs[i].Bytes()); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
-// return authtoken, area, err
-func (r *Radiko) auth(ctx context.Context) (string, string, error) {
-	req, err := http.NewRequest("GET", playerUrl, nil)
+// downloadTimeshiftSegments fetches the complete time-free chunklist for
+// [ft, to) and writes every segment in it to w.
+func (r *Radiko) downloadTimeshiftSegments(ctx context.Context, authtoken string, station string, ft time.Time, to time.Time, w io.Writer) error {
+	chunklistUrl, err := r.timeshiftChunklistUrl(ctx, authtoken, station, ft, to)
 
 	if err != nil {
-		return "", "", err
+		return err
 	}
 
-	tmpSwfFile, err := ioutil.TempFile("", "swf")
+	playlist, err := r.fetchPlaylist(ctx, authtoken, chunklistUrl)
 
 	if err != nil {
-		return "", "", err
+		return err
 	}
 
-	defer func() {
-		tmpSwfFile.Close()
-		os.Remove(tmpSwfFile.Name())
-	}()
+	return r.downloadSegmentsConcurrent(ctx, authtoken, playlist.Segments, w)
+}
+
+// chunklistUrl fetches the station's live master HLS playlist and returns
+// the URL of the media (chunklist) playlist referenced by it.
+func (r *Radiko) chunklistUrl(ctx context.Context, authtoken string, station string) (*url.URL, error) {
+	u, err := url.Parse(fmt.Sprintf("https://f-radiko.smartstream.ne.jp/%s/_definst_/simul-stream.stream/playlist.m3u8", station))
+
+	if err != nil {
+		return nil, err
+	}
+
+	v := u.Query()
+	v.Set("station_id", station)
+	v.Set("l", "15")
+	v.Set("type", "b")
+	u.RawQuery = v.Encode()
+
+	return r.resolveChunklistUrl(ctx, authtoken, u)
+}
+
+// resolveChunklistUrl fetches the master HLS playlist at masterUrl and
+// returns the URL of the media (chunklist) playlist referenced by it.
+func (r *Radiko) resolveChunklistUrl(ctx context.Context, authtoken string, masterUrl *url.URL) (*url.URL, error) {
+	master, err := r.fetchPlaylist(ctx, authtoken, masterUrl)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(master.Segments) == 0 {
+		return nil, errors.New("no media playlist found in master playlist")
+	}
+
+	return url.Parse(master.Segments[0])
+}
+
+// timeshiftChunklistUrl resolves the media playlist for the time-free
+// broadcast window [ft, to) on station.
+func (r *Radiko) timeshiftChunklistUrl(ctx context.Context, authtoken string, station string, ft time.Time, to time.Time) (*url.URL, error) {
+	u, err := url.Parse("https://radiko.jp/v2/api/ts/playlist.m3u8")
+
+	if err != nil {
+		return nil, err
+	}
+
+	v := u.Query()
+	v.Set("station_id", station)
+	v.Set("l", "15")
+	v.Set("ft", ft.Format(radikoTimeLayout))
+	v.Set("to", to.Format(radikoTimeLayout))
+	u.RawQuery = v.Encode()
+
+	return r.resolveChunklistUrl(ctx, authtoken, u)
+}
+
+func (r *Radiko) downloadSegment(ctx context.Context, authtoken string, segUrl string, w io.Writer) error {
+	req, err := http.NewRequest("GET", segUrl, nil)
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("X-Radiko-Authtoken", authtoken)
+
+	return r.httpDo(ctx, req, func(resp *http.Response, err error) error {
+		if err != nil {
+			return err
+		}
+
+		defer resp.Body.Close()
+
+		if code := resp.StatusCode; code != 200 {
+			return fmt.Errorf("not status code:200, got:%d", code)
+		}
+
+		_, err = io.Copy(w, resp.Body)
+		return err
+	})
+}
+
+// radikoPlaylist is the parsed form of an HLS m3u8 playlist, good enough
+// to drive both the master playlist (one EXT-X-STREAM-INF pointing at a
+// chunklist) and the media playlist (a list of segment URLs).
+type radikoPlaylist struct {
+	Segments       []string
+	TargetDuration time.Duration
+	EndList        bool
+}
+
+func (r *Radiko) fetchPlaylist(ctx context.Context, authtoken string, u *url.URL) (*radikoPlaylist, error) {
+	req, err := http.NewRequest("GET", u.String(), nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-Radiko-Authtoken", authtoken)
 
+	var playlist *radikoPlaylist
 	err = r.httpDo(ctx, req, func(resp *http.Response, err error) error {
 		if err != nil {
 			return err
@@ -480,49 +963,86 @@ func (r *Radiko) auth(ctx context.Context) (string, string, error) {
 
 		defer resp.Body.Close()
 
-		if _, err := io.Copy(tmpSwfFile, resp.Body); err != nil {
+		if code := resp.StatusCode; code != 200 {
+			return fmt.Errorf("not status code:200, got:%d", code)
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+
+		if err != nil {
 			return err
 		}
 
-		return nil
+		playlist, err = parseM3U8(string(body), u)
+		return err
 	})
 
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	swfextract, err := exec.LookPath("swfextract")
+	return playlist, nil
+}
 
-	if err != nil {
-		return "", "", err
+func parseM3U8(body string, base *url.URL) (*radikoPlaylist, error) {
+	playlist := &radikoPlaylist{TargetDuration: time.Second * 2}
+
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXT-X-TARGETDURATION:"):
+			sec, err := strconv.Atoi(strings.TrimPrefix(line, "#EXT-X-TARGETDURATION:"))
+			if err != nil {
+				return nil, err
+			}
+			playlist.TargetDuration = time.Duration(sec) * time.Second
+		case line == "#EXT-X-ENDLIST":
+			playlist.EndList = true
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			segUrl, err := base.Parse(line)
+			if err != nil {
+				return nil, err
+			}
+			playlist.Segments = append(playlist.Segments, segUrl.String())
+		}
 	}
 
-	tmpAuthKeyPngFile, err := ioutil.TempFile("", ".png")
+	return playlist, nil
+}
+
+// auth runs the radiko auth1/auth2 handshake and returns the authtoken
+// and area code to use for subsequent API calls.
+func (r *Radiko) auth(ctx context.Context) (string, string, error) {
+	authtoken, partialkey, err := r.auth1(ctx)
 
 	if err != nil {
 		return "", "", err
 	}
 
-	defer func() {
-		tmpAuthKeyPngFile.Close()
-		os.Remove(tmpAuthKeyPngFile.Name())
-	}()
+	area, err := r.auth2(ctx, authtoken, partialkey)
 
-	swfextractCmd := exec.Command(swfextract, "-b", "12", tmpSwfFile.Name(), "-o", tmpAuthKeyPngFile.Name())
-	if err := swfextractCmd.Run(); err != nil {
+	if err != nil {
 		return "", "", err
 	}
 
-	req, err = http.NewRequest("POST", "https://radiko.jp/v2/api/auth1_fms", nil)
+	return authtoken, area, nil
+}
+
+func (r *Radiko) auth1(ctx context.Context) (string, string, error) {
+	req, err := http.NewRequest("POST", "https://radiko.jp/v2/api/auth1", nil)
 
 	if err != nil {
 		return "", "", err
 	}
 
-	req.Header.Set("pragma", "no-cache")
-	req.Header.Set("X-Radiko-App", "pc_ts")
-	req.Header.Set("X-Radiko-App-Version", "4.0.0")
-	req.Header.Set("X-Radiko-User", "test-stream")
+	req.Header.Set("X-Radiko-App", "pc_html5")
+	req.Header.Set("X-Radiko-App-Version", "0.0.1")
+	req.Header.Set("X-Radiko-User", "dummy_user")
 	req.Header.Set("X-Radiko-Device", "pc")
 
 	var authtoken string
@@ -563,12 +1083,11 @@ func (r *Radiko) auth(ctx context.Context) (string, string, error) {
 			return err
 		}
 
-		partialkeyByt := make([]byte, keylengthI)
-		if _, err = tmpAuthKeyPngFile.ReadAt(partialkeyByt, int64(keyoffsetI)); err != nil {
-			return err
+		if keyoffsetI < 0 || keyoffsetI+keylengthI > len(radikoFullKey) {
+			return errors.New("keyoffset/keylength out of range")
 		}
 
-		partialkey = base64.StdEncoding.EncodeToString(partialkeyByt)
+		partialkey = base64.StdEncoding.EncodeToString([]byte(radikoFullKey[keyoffsetI : keyoffsetI+keylengthI]))
 
 		return nil
 	})
@@ -577,16 +1096,19 @@ func (r *Radiko) auth(ctx context.Context) (string, string, error) {
 		return "", "", err
 	}
 
-	req, err = http.NewRequest("POST", "https://radiko.jp/v2/api/auth2_fms", nil)
+	return authtoken, partialkey, nil
+}
+
+func (r *Radiko) auth2(ctx context.Context, authtoken string, partialkey string) (string, error) {
+	req, err := http.NewRequest("POST", "https://radiko.jp/v2/api/auth2", nil)
 
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
 
-	req.Header.Set("pragma", "no-cache")
-	req.Header.Set("X-Radiko-App", "pc_ts")
-	req.Header.Set("X-Radiko-App-Version", "4.0.0")
-	req.Header.Set("X-Radiko-User", "test-stream")
+	req.Header.Set("X-Radiko-App", "pc_html5")
+	req.Header.Set("X-Radiko-App-Version", "0.0.1")
+	req.Header.Set("X-Radiko-User", "dummy_user")
 	req.Header.Set("X-Radiko-Device", "pc")
 	req.Header.Set("X-Radiko-Authtoken", authtoken)
 	req.Header.Set("X-Radiko-Partialkey", partialkey)
@@ -617,10 +1139,10 @@ func (r *Radiko) auth(ctx context.Context) (string, string, error) {
 	})
 
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
 
-	return authtoken, area, nil
+	return area, nil
 }
 
 func (r *Radiko) Log(v ...interface{}) {
@@ -0,0 +1,179 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// TimeWindow restricts a Rule to programs that start within [Start, End)
+// of day, in "HH:MM" form.
+type TimeWindow struct {
+	Start string
+	End   string
+}
+
+// Contains reports whether t's time of day falls within the window.
+// End < Start (e.g. "23:00-02:00") is treated as wrapping past
+// midnight, which covers the late-night radio slots this is mostly
+// used for.
+func (w *TimeWindow) Contains(t time.Time) bool {
+	clock := t.Format("15:04")
+
+	if w.End < w.Start {
+		return clock >= w.Start || clock < w.End
+	}
+
+	return clock >= w.Start && clock < w.End
+}
+
+// Rule matches radiko programs by station, title/performer keyword and
+// broadcast slot. A zero-value field means "don't filter on this".
+type Rule struct {
+	Station    string
+	TitleRegex *regexp.Regexp
+	PfmRegex   *regexp.Regexp
+	Weekday    *time.Weekday
+	TimeWindow *TimeWindow
+	PrePad     time.Duration
+	PostPad    time.Duration
+}
+
+// Matches reports whether program p should be recorded under this rule.
+func (r *Rule) Matches(p Program) bool {
+	if r.Station != "" && r.Station != p.Station {
+		return false
+	}
+
+	if r.TitleRegex != nil && !r.TitleRegex.MatchString(p.Title) {
+		return false
+	}
+
+	if r.PfmRegex != nil && !r.PfmRegex.MatchString(p.Pfm) {
+		return false
+	}
+
+	if r.Weekday != nil && *r.Weekday != p.Ft.Weekday() {
+		return false
+	}
+
+	if r.TimeWindow != nil && !r.TimeWindow.Contains(p.Ft) {
+		return false
+	}
+
+	return true
+}
+
+// ruleFile is the on-disk (JSON) shape of a rules file entry.
+type ruleFile struct {
+	Station    string `json:"station"`
+	TitleRegex string `json:"title_regex"`
+	PfmRegex   string `json:"pfm_regex"`
+	Weekday    string `json:"weekday"`
+	TimeWindow string `json:"time_window"`
+	PrePad     string `json:"pre_pad"`
+	PostPad    string `json:"post_pad"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// LoadRules reads a JSON rules file of the form
+//
+//	[
+//	  {"station": "TBS", "title_regex": "アルコ&ピース", "pre_pad": "1m", "post_pad": "2m"},
+//	  {"station": "QRR", "weekday": "saturday", "time_window": "09:00-12:00"}
+//	]
+func LoadRules(path string) ([]Rule, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var files []ruleFile
+
+	if err := json.NewDecoder(f).Decode(&files); err != nil {
+		return nil, err
+	}
+
+	rules := make([]Rule, len(files))
+
+	for i, rf := range files {
+		rule, err := compileRule(rf)
+
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+
+		rules[i] = rule
+	}
+
+	return rules, nil
+}
+
+func compileRule(rf ruleFile) (Rule, error) {
+	rule := Rule{Station: rf.Station}
+
+	if rf.TitleRegex != "" {
+		re, err := regexp.Compile(rf.TitleRegex)
+		if err != nil {
+			return rule, err
+		}
+		rule.TitleRegex = re
+	}
+
+	if rf.PfmRegex != "" {
+		re, err := regexp.Compile(rf.PfmRegex)
+		if err != nil {
+			return rule, err
+		}
+		rule.PfmRegex = re
+	}
+
+	if rf.Weekday != "" {
+		weekday, ok := weekdays[strings.ToLower(rf.Weekday)]
+		if !ok {
+			return rule, fmt.Errorf("unknown weekday %q", rf.Weekday)
+		}
+		rule.Weekday = &weekday
+	}
+
+	if rf.TimeWindow != "" {
+		parts := strings.SplitN(rf.TimeWindow, "-", 2)
+		if len(parts) != 2 {
+			return rule, fmt.Errorf("time_window must be HH:MM-HH:MM, got %q", rf.TimeWindow)
+		}
+		rule.TimeWindow = &TimeWindow{Start: parts[0], End: parts[1]}
+	}
+
+	if rf.PrePad != "" {
+		d, err := time.ParseDuration(rf.PrePad)
+		if err != nil {
+			return rule, err
+		}
+		rule.PrePad = d
+	}
+
+	if rf.PostPad != "" {
+		d, err := time.ParseDuration(rf.PostPad)
+		if err != nil {
+			return rule, err
+		}
+		rule.PostPad = d
+	}
+
+	return rule, nil
+}
@@ -0,0 +1,162 @@
+// Package scheduler matches upcoming radiko programs against a set of
+// keyword rules and records each match once, at its own broadcast time.
+//
+// This replaces the "record whatever station X airs right now" model in
+// Radiko.Run with "record this specific program whenever it airs", so a
+// rule like {station: TBS, title_regex: "ACTION"} keeps working across
+// days without a cron entry per episode.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Program is the subset of a radiko broadcast the scheduler needs to
+// match it against rules and schedule its recording.
+type Program struct {
+	Station string
+	Title   string
+	Pfm     string
+	Ft      time.Time
+	To      time.Time
+}
+
+// ProgramSource lists the programs scheduled to air today, analogous to
+// Radiko.todayPrograms.
+type ProgramSource interface {
+	TodayPrograms(ctx context.Context) ([]Program, error)
+}
+
+// Recorder records a single program. start and duration already include
+// the rule's pre/post padding.
+type Recorder interface {
+	Record(ctx context.Context, p Program, start time.Time, duration time.Duration) error
+}
+
+// Scheduler polls a ProgramSource on an interval, matches newly seen
+// programs against Rules and hands matches to Recorder at the right
+// time. It records each (station, Ft) pair at most once.
+type Scheduler struct {
+	Rules     []Rule
+	Source    ProgramSource
+	Recorder  Recorder
+	scheduled map[string]bool
+}
+
+// New builds a Scheduler ready to Run.
+func New(rules []Rule, source ProgramSource, recorder Recorder) *Scheduler {
+	return &Scheduler{
+		Rules:     rules,
+		Source:    source,
+		Recorder:  recorder,
+		scheduled: map[string]bool{},
+	}
+}
+
+// Run polls the program source every pollInterval until ctx is done,
+// scheduling a recording for each newly matched program.
+func (s *Scheduler) Run(ctx context.Context, pollInterval time.Duration) error {
+	if err := s.poll(ctx); err != nil {
+		s.Log("poll err:", err)
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := s.poll(ctx); err != nil {
+				s.Log("poll err:", err)
+			}
+		}
+	}
+}
+
+func (s *Scheduler) poll(ctx context.Context) error {
+	programs, err := s.Source.TodayPrograms(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, p := range programs {
+		if p.To.Before(now) {
+			continue
+		}
+
+		rule := s.match(p)
+
+		if rule == nil {
+			continue
+		}
+
+		key := fmt.Sprintf("%s|%s", p.Station, p.Ft.Format(time.RFC3339))
+
+		if s.scheduled[key] {
+			continue
+		}
+
+		s.scheduled[key] = true
+
+		s.enqueue(ctx, p, rule)
+	}
+
+	return nil
+}
+
+func (s *Scheduler) match(p Program) *Rule {
+	for i := range s.Rules {
+		if s.Rules[i].Matches(p) {
+			return &s.Rules[i]
+		}
+	}
+
+	return nil
+}
+
+// enqueue waits until the program's (padded) start time and then hands
+// it to Recorder. Unlike Radiko.run's retry loop, the program and its
+// duration are fixed up front and a failed recording is only logged, not
+// retried, so it never falls back to re-recording whatever is airing now.
+func (s *Scheduler) enqueue(ctx context.Context, p Program, rule *Rule) {
+	start := p.Ft.Add(-rule.PrePad)
+	end := p.To.Add(rule.PostPad)
+
+	s.Log(fmt.Sprintf("scheduled %q on %s at %s for %s", p.Title, p.Station, start, end.Sub(start)))
+
+	time.AfterFunc(time.Until(start), func() {
+		// start is in the past when the program was already airing by
+		// the time it was first polled (e.g. right after the scheduler
+		// starts up); AfterFunc then fires immediately instead of at
+		// start. Record from now, and clamp duration to what's left
+		// until end, so a late start doesn't over-record into the next
+		// program's slot.
+		recordStart := start
+		if now := time.Now(); now.After(recordStart) {
+			recordStart = now
+		}
+
+		duration := end.Sub(recordStart)
+
+		if duration <= 0 {
+			s.Log(fmt.Sprintf("skip %q on %s: already over by the time it was recorded", p.Title, p.Station))
+			return
+		}
+
+		if err := s.Recorder.Record(ctx, p, recordStart, duration); err != nil {
+			s.Log(fmt.Sprintf("record %q on %s failed: %s", p.Title, p.Station, err))
+		}
+	})
+}
+
+func (s *Scheduler) Log(v ...interface{}) {
+	log.Println("[scheduler]", fmt.Sprint(v...))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/yoshiooto/radicast/scheduler"
+)
+
+// RadikoProgramSource adapts Radiko.todayPrograms to scheduler.ProgramSource.
+type RadikoProgramSource struct {
+	Radiko *Radiko
+}
+
+func (s *RadikoProgramSource) TodayPrograms(ctx context.Context) ([]scheduler.Program, error) {
+	_, area, err := s.Radiko.auth(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	progs, err := s.Radiko.todayPrograms(ctx, area)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var out []scheduler.Program
+
+	for _, station := range progs.Stations.Station {
+		for _, prog := range station.Scd.Progs.Prog {
+			ft, err := prog.FtTime()
+			if err != nil {
+				continue
+			}
+
+			to, err := prog.ToTime()
+			if err != nil {
+				continue
+			}
+
+			out = append(out, scheduler.Program{
+				Station: station.Id,
+				Title:   prog.Title,
+				Pfm:     prog.Pfm,
+				Ft:      ft,
+				To:      to,
+			})
+		}
+	}
+
+	return out, nil
+}
+
+// RadikoRecorder adapts Radiko.RecordProgram to scheduler.Recorder,
+// saving each recording under OutputDir the same way a live Radiko.Run
+// result would be saved.
+type RadikoRecorder struct {
+	Converter string
+	Bitrate   string
+	TempDir   string
+	OutputDir string
+}
+
+func (r *RadikoRecorder) Record(ctx context.Context, p scheduler.Program, start time.Time, duration time.Duration) error {
+	radiko := &Radiko{
+		Station:   p.Station,
+		Bitrate:   r.Bitrate,
+		Converter: r.Converter,
+		TempDir:   r.TempDir,
+	}
+
+	prog := &RadikoProg{
+		Ft:    p.Ft.Format(radikoTimeLayout),
+		To:    p.To.Format(radikoTimeLayout),
+		Title: p.Title,
+		Pfm:   p.Pfm,
+	}
+
+	if err := radiko.RecordProgram(ctx, p.Station, prog, int64(duration.Seconds())); err != nil {
+		return err
+	}
+
+	return radiko.Result.Save(ctx, r.OutputDir)
+}
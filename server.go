@@ -12,14 +12,41 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
+
+	"github.com/yoshiooto/radicast/hls"
+)
+
+const (
+	hlsSegmentDuration = 6 * time.Second
+	hlsWindowSize      = 10
+
+	// liveIdleCheck is how often a live ring's watchdog polls for
+	// inactivity.
+	liveIdleCheck = 10 * time.Second
+
+	// closeAfterInactivity is how long a live ring survives without a
+	// /live request before its watchdog drops it, on the assumption
+	// every listener has disconnected.
+	closeAfterInactivity = 2 * time.Minute
 )
 
 type Server struct {
 	Output string
 	Title  string
 	Addr   string
+
+	liveMu       sync.Mutex
+	live         map[string]*hls.Ring
+	liveAccessed map[string]time.Time
+
+	finishedMu sync.Mutex
+	finished   map[string]string
 }
 
 func (s *Server) errorHandler(f func(http.ResponseWriter, *http.Request) error) func(http.ResponseWriter, *http.Request) {
@@ -66,41 +93,226 @@ func (s *Server) Run() error {
 		return nil
 	}))
 
-	router.HandleFunc("/rss", s.errorHandler(func(w http.ResponseWriter, r *http.Request) error {
+	router.HandleFunc("/podcast/{program}/cover.jpg", s.errorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		dir := mux.Vars(r)["program"]
 
-		baseUrl, err := url.Parse("http://" + r.Host)
+		coverPath, coverStat, err := s.coverPath(dir)
 
 		if err != nil {
-			return err
+			http.NotFound(w, r)
+			return nil
 		}
 
-		rss, err := s.rss(baseUrl)
+		f, err := os.Open(coverPath)
 
 		if err != nil {
 			return err
 		}
 
-		var b bytes.Buffer
+		defer f.Close()
+
+		http.ServeContent(w, r, coverStat.Name(), coverStat.ModTime(), f)
+		return nil
+	}))
 
-		b.WriteString(xml.Header)
+	router.HandleFunc("/rss", s.errorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		return s.serveRss(w, r, "")
+	}))
 
-		enc := xml.NewEncoder(&b)
-		enc.Indent("", "    ")
-		if err := enc.Encode(rss); err != nil {
-			return err
+	router.HandleFunc("/rss/{station}", s.errorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		return s.serveRss(w, r, mux.Vars(r)["station"])
+	}))
+
+	router.HandleFunc("/live/{station}.m3u8", s.errorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		station := mux.Vars(r)["station"]
+
+		ring := s.liveRing(station)
+
+		if ring == nil {
+			programDir, ok := s.finishedProgramDir(station)
+
+			if !ok {
+				http.NotFound(w, r)
+				return nil
+			}
+
+			http.Redirect(w, r, "/podcast/"+programDir+".m4a", http.StatusFound)
+			return nil
 		}
 
-		if _, err := io.Copy(w, &b); err != nil {
-			return err
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		// Ring.Playlist lists segments as bare "seg-N.ts", which a player
+		// resolves against /live/ rather than /live/{station}/, so point
+		// each one into this station's segment route.
+		playlist := strings.Replace(ring.Playlist(hlsSegmentDuration), "\nseg-", "\n"+station+"/seg-", -1)
+		_, err := io.WriteString(w, playlist)
+		return err
+	}))
+
+	router.HandleFunc("/live/{station}/seg-{seq}.ts", s.errorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		vars := mux.Vars(r)
+		station := vars["station"]
+
+		seq, err := strconv.Atoi(vars["seq"])
+
+		if err != nil {
+			http.NotFound(w, r)
+			return nil
 		}
 
-		return nil
+		ring := s.liveRing(station)
+
+		if ring == nil {
+			http.NotFound(w, r)
+			return nil
+		}
+
+		data, ok := ring.Segment(seq)
+
+		if !ok {
+			http.NotFound(w, r)
+			return nil
+		}
+
+		w.Header().Set("Content-Type", "video/mp2t")
+		_, err = w.Write(data)
+		return err
 	}))
 
 	return http.ListenAndServe(s.Addr, router)
 }
 
-func (s *Server) rss(baseUrl *url.URL) (*PodcastRss, error) {
+// RegisterLive starts a live HLS endpoint for station, backed by a
+// rolling ring buffer of hlsWindowSize segments, and returns an
+// io.WriteCloser to tee the recording's encoded TS stream into. Callers
+// must Close it and then call FinishLive once the recording ends. A
+// background watchdog also drops the ring early if it goes
+// closeAfterInactivity without a /live request, in case every listener
+// disconnects long before the recording itself finishes.
+func (s *Server) RegisterLive(station string) *hls.SegmentWriter {
+	ring := hls.NewRing(hlsWindowSize)
+	writer := hls.NewSegmentWriter(ring, hlsSegmentDuration)
+
+	s.liveMu.Lock()
+	if s.live == nil {
+		s.live = map[string]*hls.Ring{}
+	}
+	if s.liveAccessed == nil {
+		s.liveAccessed = map[string]time.Time{}
+	}
+	s.live[station] = ring
+	s.liveAccessed[station] = time.Now()
+	s.liveMu.Unlock()
+
+	go s.watchLiveIdle(station, ring)
+
+	return writer
+}
+
+// watchLiveIdle drops station's live ring once closeAfterInactivity has
+// passed since the last /live request for it. It exits as soon as ring
+// is no longer the registered ring for station, whether that's because
+// it evicted it itself or FinishLive/a later RegisterLive already did.
+func (s *Server) watchLiveIdle(station string, ring *hls.Ring) {
+	ticker := time.NewTicker(liveIdleCheck)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.liveMu.Lock()
+
+		if s.live[station] != ring {
+			s.liveMu.Unlock()
+			return
+		}
+
+		idle := time.Since(s.liveAccessed[station])
+
+		if idle > closeAfterInactivity {
+			delete(s.live, station)
+			delete(s.liveAccessed, station)
+			s.liveMu.Unlock()
+			return
+		}
+
+		s.liveMu.Unlock()
+	}
+}
+
+// FinishLive drops station's live ring buffer (the idle watchdog from
+// RegisterLive may already have done so, if every listener disconnected
+// before the recording ended) and makes /live/{station}.m3u8 redirect
+// future requests at the finished episode under programDir (the
+// recording's {Ft}_{Station} directory name).
+func (s *Server) FinishLive(station string, programDir string) {
+	s.liveMu.Lock()
+	delete(s.live, station)
+	delete(s.liveAccessed, station)
+	s.liveMu.Unlock()
+
+	s.finishedMu.Lock()
+	defer s.finishedMu.Unlock()
+
+	if s.finished == nil {
+		s.finished = map[string]string{}
+	}
+	s.finished[station] = programDir
+}
+
+func (s *Server) liveRing(station string) *hls.Ring {
+	s.liveMu.Lock()
+	defer s.liveMu.Unlock()
+
+	ring, ok := s.live[station]
+
+	if ok {
+		if s.liveAccessed == nil {
+			s.liveAccessed = map[string]time.Time{}
+		}
+		s.liveAccessed[station] = time.Now()
+	}
+
+	return ring
+}
+
+func (s *Server) finishedProgramDir(station string) (string, bool) {
+	s.finishedMu.Lock()
+	defer s.finishedMu.Unlock()
+
+	dir, ok := s.finished[station]
+	return dir, ok
+}
+
+func (s *Server) serveRss(w http.ResponseWriter, r *http.Request, station string) error {
+	baseUrl, err := url.Parse("http://" + r.Host)
+
+	if err != nil {
+		return err
+	}
+
+	rss, err := s.rss(baseUrl, station)
+
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+
+	b.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(&b)
+	enc.Indent("", "    ")
+	if err := enc.Encode(rss); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, &b)
+	return err
+}
+
+// rss builds the feed for every recorded program, or, when station is
+// non-empty, only the ones recorded under that station (directories are
+// named "{Ft}_{Station}", so this is a suffix match).
+func (s *Server) rss(baseUrl *url.URL, station string) (*PodcastRss, error) {
 
 	dirs, err := ioutil.ReadDir(s.Output)
 
@@ -115,6 +327,10 @@ func (s *Server) rss(baseUrl *url.URL) (*PodcastRss, error) {
 			continue
 		}
 
+		if station != "" && !strings.HasSuffix(dir.Name(), "_"+station) {
+			continue
+		}
+
 		item, err := s.itemByDir(dir.Name(), baseUrl)
 
 		if err != nil {
@@ -131,6 +347,17 @@ func (s *Server) rss(baseUrl *url.URL) (*PodcastRss, error) {
 
 	channel := PodcastChannel{}
 	channel.Title = s.Title
+	if station != "" {
+		channel.Title = fmt.Sprintf("%s (%s)", s.Title, station)
+	}
+	channel.Language = "ja-jp"
+	channel.ItunesType = "episodic"
+	channel.ItunesExplicit = "no"
+	channel.ItunesCategory = &ItunesCategory{Text: "Society & Culture"}
+	channel.ItunesOwner = &ItunesOwner{Name: s.Title}
+	if len(items) > 0 {
+		channel.ItunesImage = items[0].ITunesImage
+	}
 	channel.Items = items
 
 	rss.Channel = channel
@@ -173,13 +400,28 @@ func (s *Server) itemByDir(dir string, baseUrl *url.URL) (*PodcastItem, error) {
 		return nil, err
 	}
 
+	coverUrl, err := url.Parse("/podcast/" + dir + "/cover.jpg")
+
+	if err != nil {
+		return nil, err
+	}
+
 	ft, _ := prog.FtTime()
+	to, _ := prog.ToTime()
 
 	var item PodcastItem
 
 	item.Title = fmt.Sprintf("%s (%s)", prog.Title, ft)
+	item.Guid = dir
 	item.ITunesAuthor = prog.Pfm
 	item.ITunesSummary = prog.Info
+	item.ITunesDuration = formatItunesDuration(to.Sub(ft))
+	item.ITunesSeason = ft.Year()
+	item.ITunesEpisode = ft.YearDay()
+
+	if _, _, err := s.coverPath(dir); err == nil {
+		item.ITunesImage = &ItunesImage{Href: baseUrl.ResolveReference(coverUrl).String()}
+	}
 
 	item.Enclosure.Url = baseUrl.ResolveReference(u).String()
 	item.Enclosure.Type = "audio/mpeg"
@@ -189,6 +431,12 @@ func (s *Server) itemByDir(dir string, baseUrl *url.URL) (*PodcastItem, error) {
 	return &item, nil
 }
 
+// formatItunesDuration renders d as the HH:MM:SS itunes:duration wants.
+func formatItunesDuration(d time.Duration) string {
+	sec := int(d.Seconds())
+	return fmt.Sprintf("%02d:%02d:%02d", sec/3600, (sec%3600)/60, sec%60)
+}
+
 func (s *Server) m4aPath(dir string) (string, os.FileInfo, error) {
 	return s.pathStat(dir, "podcast.m4a")
 }
@@ -197,6 +445,10 @@ func (s *Server) xmlPath(dir string) (string, os.FileInfo, error) {
 	return s.pathStat(dir, "podcast.xml")
 }
 
+func (s *Server) coverPath(dir string) (string, os.FileInfo, error) {
+	return s.pathStat(dir, "cover.jpg")
+}
+
 func (s *Server) pathStat(dir string, name string) (string, os.FileInfo, error) {
 	p := filepath.Join(s.Output, dir, name)
 	stat, err := os.Stat(p)